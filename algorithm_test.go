@@ -0,0 +1,121 @@
+package httpsign
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRSASignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(err)
+
+	signer := NewRSA(priv, nil)
+	verifier := NewRSA(nil, &priv.PublicKey)
+
+	value := randomString(25)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.Verify(h, v))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(value))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestNewECDSASignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(err)
+
+	signer := NewECDSA(priv, nil)
+	verifier := NewECDSA(nil, &priv.PublicKey)
+
+	value := randomString(25)
+	header := signer.GenerateHeaderValue(value)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.Verify(h, v))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(verifier.HeaderName, header)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestNewEd25519SignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+
+	signer := NewEd25519(priv, nil)
+	verifier := NewEd25519(nil, pub)
+
+	value := randomString(25)
+	header := signer.GenerateHeaderValue(value)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.Verify(h, v))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(verifier.HeaderName, header)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestVerifyRejectsUnexpectedAlgorithm(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+
+	// Sign with Ed25519 but verify with an HMAC HttpSign: the algorithm
+	// identifier in the header should not match and the request should be
+	// rejected, rather than the verifier silently trusting the claimed alg.
+	signer := NewEd25519(priv, pub)
+	verifier := New(key)
+
+	value := randomString(25)
+	header := signer.GenerateHeaderValue(value)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.Verify(h, v))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(verifier.HeaderName, header)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
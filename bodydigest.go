@@ -0,0 +1,135 @@
+package httpsign
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// bodyDigestKey is the context key VerifyRequest uses to stash the pending
+// body-digest check so Commit can find and finalize it later.
+type bodyDigestKey struct{}
+
+// bodyDigestVerifier accumulates a streamed SHA-256 hash of a request body
+// and compares it, once finalized, against the Content-Digest value that
+// was already authenticated as part of the request's signature.
+type bodyDigestVerifier struct {
+	hs       *HttpSign
+	w        http.ResponseWriter
+	r        *http.Request
+	hasher   hash.Hash
+	expected string
+	maxBytes int64
+	read     int64
+	done     bool
+	err      error
+}
+
+// finalize compares the streamed hash against the expected Content-Digest
+// the first time it is called; later calls just return the recorded
+// result. It aborts the connection on mismatch.
+func (v *bodyDigestVerifier) finalize() error {
+	if v.done {
+		return v.err
+	}
+	v.done = true
+
+	sum := v.hasher.Sum(nil)
+	actual := fmt.Sprintf("sha-256=:%s:", base64.StdEncoding.EncodeToString(sum))
+	if actual != v.expected {
+		v.err = fmt.Errorf("Content-Digest mismatch (claimed=%s, actual=%s)", v.expected, actual)
+		v.hs.log(v.r, "%s", v.err)
+		v.hs.abortConnection(v.w)
+	}
+	return v.err
+}
+
+// teeBodyReader wraps a request body, streaming every byte read by the
+// handler into verifier's hasher instead of buffering the body up front.
+// Finalization happens as soon as the handler reaches EOF, or earlier via
+// Commit for handlers that stop reading before then.
+type teeBodyReader struct {
+	orig     io.ReadCloser
+	verifier *bodyDigestVerifier
+}
+
+func (t *teeBodyReader) Read(p []byte) (int, error) {
+	n, err := t.orig.Read(p)
+	if n > 0 {
+		t.verifier.hasher.Write(p[:n])
+		t.verifier.read += int64(n)
+		if t.verifier.maxBytes > 0 && t.verifier.read > t.verifier.maxBytes {
+			oversized := fmt.Errorf("request body exceeds MaxBodyBytes (%d)", t.verifier.maxBytes)
+			t.verifier.hs.log(t.verifier.r, "%s", oversized)
+			t.verifier.hs.abortConnection(t.verifier.w)
+			return n, oversized
+		}
+	}
+	if err == io.EOF {
+		if ferr := t.verifier.finalize(); ferr != nil {
+			return n, ferr
+		}
+	}
+	return n, err
+}
+
+func (t *teeBodyReader) Close() error {
+	return t.orig.Close()
+}
+
+// abortConnection abandons the response rather than letting a handler send
+// a reply over a body that turned out not to match its claimed digest. For
+// HTTP/1.1 this hijacks and closes the raw connection so the client can't
+// mistake anything already written for a trusted response; ResponseWriters
+// that don't support hijacking (e.g. HTTP/2) can't be reset this way, so a
+// trailer is set instead for clients that check it.
+func (hs *HttpSign) abortConnection(w http.ResponseWriter) {
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	w.Header().Set("Trailer", "X-Content-Digest-Invalid")
+	w.Header().Set("X-Content-Digest-Invalid", "true")
+}
+
+// wrapBodyForDigestVerification replaces r.Body with one that streams into
+// a rolling hasher instead of reading it all up front, so VerifyRequest can
+// cover a Content-Digest-bound body without buffering large uploads into
+// memory. The actual comparison is deferred to finalize, invoked once the
+// handler reaches EOF or Commit is called explicitly.
+func (hs *HttpSign) wrapBodyForDigestVerification(w http.ResponseWriter, r *http.Request) *http.Request {
+	verifier := &bodyDigestVerifier{
+		hs:       hs,
+		w:        w,
+		r:        r,
+		hasher:   sha256.New(),
+		expected: r.Header.Get("Content-Digest"),
+		maxBytes: hs.MaxBodyBytes,
+	}
+	r.Body = &teeBodyReader{orig: r.Body, verifier: verifier}
+	return r.WithContext(context.WithValue(r.Context(), bodyDigestKey{}, verifier))
+}
+
+// Commit forces any pending streamed body-digest check for r to run now.
+// VerifyRequest already triggers this automatically once the body is read
+// through to EOF, so most handlers never need to call it; it exists for
+// handlers that stop reading the body early (e.g. after a validation
+// failure) but still need the digest enforced before they respond.
+func (hs *HttpSign) Commit(r *http.Request) error {
+	verifier, ok := r.Context().Value(bodyDigestKey{}).(*bodyDigestVerifier)
+	if !ok {
+		return nil
+	}
+	// Drain whatever the handler left unread through the tee, so the
+	// digest is computed over the whole body rather than a prefix of it.
+	if !verifier.done && r.Body != nil {
+		io.Copy(io.Discard, r.Body)
+	}
+	return verifier.finalize()
+}
@@ -0,0 +1,139 @@
+package httpsign
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyRequestStreamsLargeBodyWithoutBuffering(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	opts := RequestOptions{Method: true, Path: true, Body: true}
+
+	signer := New(key)
+	verifier := New(key)
+
+	payload := strings.Repeat("x", 5*1024*1024) // large enough that buffering it up front would be wasteful
+
+	var gotLen int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(err)
+		gotLen = len(body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(verifier.VerifyRequest(h, opts))
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/widgets", strings.NewReader(payload))
+	assert.Nil(err)
+	digest, err := computeContentDigest(req)
+	assert.Nil(err)
+	req.Header.Set("Content-Digest", digest)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(opts.value(req, true)))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(len(payload), gotLen)
+}
+
+func TestVerifyRequestAbortsOnBodyDigestMismatch(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	opts := RequestOptions{Method: true, Path: true, Body: true}
+
+	signer := New(key)
+	verifier := New(key)
+	var lastLogMsg string
+	done := make(chan struct{})
+	verifier.LogHook = func(r *http.Request, msg string) {
+		lastLogMsg = msg
+		close(done)
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(verifier.VerifyRequest(h, opts))
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/widgets", strings.NewReader("original payload"))
+	assert.Nil(err)
+	digest, err := computeContentDigest(req)
+	assert.Nil(err)
+	req.Header.Set("Content-Digest", digest)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(opts.value(req, true)))
+
+	// Swap the body after signing, so the signature covers the original
+	// digest but the bytes that actually stream in hash differently. Keep
+	// the length identical so Content-Length still matches what was sent.
+	req.Body = io.NopCloser(strings.NewReader("original PAYLOAD"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	// The middleware hijacks and closes the connection rather than letting
+	// the handler's response through, so the client sees no valid response.
+	assert.True(err != nil || resp.StatusCode != http.StatusOK)
+
+	// finalize() runs on the server goroutine as the handler drains the
+	// body, asynchronously from the client's Do() returning; wait for the
+	// LogHook rather than reading lastLogMsg racily.
+	<-done
+	assert.Contains(lastLogMsg, "Content-Digest mismatch")
+}
+
+func TestVerifyRequestEnforcesMaxBodyBytes(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	opts := RequestOptions{Method: true, Path: true, Body: true}
+
+	signer := New(key)
+	verifier := New(key)
+	verifier.MaxBodyBytes = 4
+	var lastLogMsg string
+	done := make(chan struct{})
+	verifier.LogHook = func(r *http.Request, msg string) {
+		lastLogMsg = msg
+		close(done)
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(verifier.VerifyRequest(h, opts))
+	defer ts.Close()
+
+	payload := "this payload is longer than the MaxBodyBytes guard allows"
+	req, err := http.NewRequest("POST", ts.URL+"/widgets", strings.NewReader(payload))
+	assert.Nil(err)
+	digest, err := computeContentDigest(req)
+	assert.Nil(err)
+	req.Header.Set("Content-Digest", digest)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(opts.value(req, true)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	assert.True(err != nil || resp.StatusCode != http.StatusOK)
+
+	// The MaxBodyBytes LogHook call happens on the server goroutine while
+	// streaming the body, asynchronously from Do() returning; wait for it
+	// rather than reading lastLogMsg racily.
+	<-done
+	assert.Contains(lastLogMsg, "exceeds MaxBodyBytes")
+}
@@ -1,9 +1,14 @@
 // Package httpsign provides HTTP Middleware for signing and verifying
-// HMAC SHA256 signatures for trusting the source of a request
+// HMAC SHA256, RSA, ECDSA and Ed25519 signatures for trusting the source of
+// a request
 package httpsign
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
@@ -21,26 +26,86 @@ type GetValue func(w http.ResponseWriter, r *http.Request) string
 // A function to call for logging problems, currently only used in Verify().
 type LogHook func(r *http.Request, msg string)
 
+// KeyResolver looks up the shared HMAC secret for keyID, the key identifier
+// embedded in the signed header by the signing side's KeyID. It is used by
+// Verify to support key rotation and per-tenant keys: different requests
+// can be signed with different keys without the verifier needing to know
+// in advance which key was used.
+type KeyResolver func(keyID string, r *http.Request) ([]byte, error)
+
 // HttpSign is the main package object
 type HttpSign struct {
 	HeaderName       string
 	SecondsAllowance int
-	Key              []byte
-	DisableVerify    bool // Supports testing by disabling the checking in Verify()
+	Key              []byte // shared secret used by the default HMAC-SHA256 Algorithm
+	Algorithm        Algorithm
+	KeyID            string      // embedded in the signed header to identify Key/Algorithm to the verifier
+	KeyResolver      KeyResolver // when set, Verify resolves the HMAC key to use from the header's keyid
+	NonceStore       NonceStore  // when set, Verify rejects requests whose nonce has already been seen
+	DisableVerify    bool        // Supports testing by disabling the checking in Verify()
 	LogHook          LogHook
+
+	// MaxBodyBytes, when non-zero, bounds how many bytes VerifyRequest will
+	// stream into a request's Content-Digest check before aborting the
+	// connection, so a client can't force unbounded reads before the
+	// digest is found to mismatch.
+	MaxBodyBytes int64
 }
 
 // New returns a pointer to a HttpSign object configured with the key and with
-// the package defaults.
+// the package defaults. Signing and verification use HMAC-SHA256.
 func New(key []byte) *HttpSign {
 	httpSign := HttpSign{
 		HeaderName:       "X-Signature",
 		SecondsAllowance: 6,
 		Key:              key,
+		Algorithm:        &hmacAlgorithm{key: key},
 	}
 	return &httpSign
 }
 
+// NewRSA returns a pointer to a HttpSign object that signs with priv and/or
+// verifies with pub using RSA PKCS#1 v1.5 with SHA-256. Either key may be
+// nil for a HttpSign that only signs or only verifies.
+func NewRSA(priv *rsa.PrivateKey, pub *rsa.PublicKey) *HttpSign {
+	return &HttpSign{
+		HeaderName:       "X-Signature",
+		SecondsAllowance: 6,
+		Algorithm:        &rsaAlgorithm{priv: priv, pub: pub},
+	}
+}
+
+// NewECDSA returns a pointer to a HttpSign object that signs with priv
+// and/or verifies with pub using ECDSA P-256 with SHA-256. Either key may
+// be nil for a HttpSign that only signs or only verifies.
+func NewECDSA(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) *HttpSign {
+	return &HttpSign{
+		HeaderName:       "X-Signature",
+		SecondsAllowance: 6,
+		Algorithm:        &ecdsaAlgorithm{priv: priv, pub: pub},
+	}
+}
+
+// NewEd25519 returns a pointer to a HttpSign object that signs with priv
+// and/or verifies with pub using Ed25519. Either key may be nil for a
+// HttpSign that only signs or only verifies.
+func NewEd25519(priv ed25519.PrivateKey, pub ed25519.PublicKey) *HttpSign {
+	return &HttpSign{
+		HeaderName:       "X-Signature",
+		SecondsAllowance: 6,
+		Algorithm:        &ed25519Algorithm{priv: priv, pub: pub},
+	}
+}
+
+// algorithm returns the configured Algorithm, falling back to HMAC-SHA256
+// over Key for a HttpSign built as a struct literal rather than via New().
+func (hs *HttpSign) algorithm() Algorithm {
+	if hs.Algorithm != nil {
+		return hs.Algorithm
+	}
+	return &hmacAlgorithm{key: hs.Key}
+}
+
 func (hs *HttpSign) log(r *http.Request, msgPattern string, args ...interface{}) {
 	if hs.LogHook != nil {
 		hs.LogHook(r, fmt.Sprintf(msgPattern, args...))
@@ -55,8 +120,20 @@ func (hs *HttpSign) SignToProxy(h http.Handler, v GetValue) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		value := v(w, r)
 		epoch := time.Now().Unix()
-		signature := calcHMAC(hs.Key, value, epoch)
-		r.Header.Add(hs.HeaderName, formHeader(signature, epoch))
+		algorithm := hs.algorithm()
+		nonce, err := generateNonce()
+		if err != nil {
+			hs.log(r, "Unable to generate nonce: %s", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		signature, err := algorithm.Sign(formMessage(value, epoch, nonce))
+		if err != nil {
+			hs.log(r, "Unable to sign request: %s", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Add(hs.HeaderName, formHeader(hs.KeyID, algorithm.Name(), string(signature), epoch, nonce))
 		h.ServeHTTP(w, r)
 	})
 }
@@ -69,44 +146,79 @@ func (hs *HttpSign) SignToProxy(h http.Handler, v GetValue) http.Handler {
 // Otherwise it will call the next middleware in the chain.
 func (hs *HttpSign) Verify(h http.Handler, v GetValue) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		header := r.Header.Get(hs.HeaderName)
 		if hs.DisableVerify {
 			h.ServeHTTP(w, r)
 			return
 		}
-		expectedSignature, expectedEpoch, err := parseHeader(header)
-		if err != nil {
-			hs.log(r, "Unable to parse header '%s'", header)
+		if err := hs.checkSignature(w, r, v); err != nil {
+			hs.log(r, "%s", err)
 			hs.writeInvalid(w)
 			return
 		}
-		now := time.Now().Unix()
-		if now > expectedEpoch+int64(hs.SecondsAllowance) {
-			hs.log(r, "Stale timestamp %d (now=%d, allowance=%d)", expectedEpoch, now, hs.SecondsAllowance)
-			hs.writeInvalid(w)
-			return
+		h.ServeHTTP(w, r)
+	})
+}
+
+// checkSignature parses and validates the signature carried by r's
+// HeaderName header against the value returned by v, returning a
+// descriptive error if the request should be rejected. It is shared by
+// Verify and VerifyRequest.
+func (hs *HttpSign) checkSignature(w http.ResponseWriter, r *http.Request, v GetValue) error {
+	header := r.Header.Get(hs.HeaderName)
+	keyID, alg, expectedSignature, expectedEpoch, nonce, err := parseHeader(header)
+	if err != nil {
+		return fmt.Errorf("Unable to parse header '%s'", header)
+	}
+	algorithm := hs.algorithm()
+	if hs.KeyResolver != nil {
+		key, err := hs.KeyResolver(keyID, r)
+		if err != nil {
+			return fmt.Errorf("Unable to resolve key '%s': %s", keyID, err)
 		}
+		algorithm = &hmacAlgorithm{key: key}
+	}
+	if alg != algorithm.Name() {
+		return fmt.Errorf("Unexpected algorithm '%s' (expected '%s')", alg, algorithm.Name())
+	}
+	now := time.Now().Unix()
+	if now > expectedEpoch+int64(hs.SecondsAllowance) {
+		return fmt.Errorf("Stale timestamp %d (now=%d, allowance=%d)", expectedEpoch, now, hs.SecondsAllowance)
+	}
 
-		value := v(w, r)
-		signature := calcHMAC(hs.Key, value, expectedEpoch)
+	value := v(w, r)
+	if err := algorithm.Verify(formMessage(value, expectedEpoch, nonce), []byte(expectedSignature)); err != nil {
+		return fmt.Errorf("Signature mismatch: %s (header=%s)", err, header)
+	}
 
-		if signature != expectedSignature {
-			hs.log(r, "Signature mismatch %s (calculated=%s, header=%s)", expectedSignature, signature, header)
-			hs.writeInvalid(w)
-			return
+	if hs.NonceStore != nil {
+		expiresAt := time.Unix(expectedEpoch, 0).Add(time.Duration(hs.SecondsAllowance) * time.Second)
+		seen, err := hs.NonceStore.Seen(nonce, expiresAt)
+		if err != nil {
+			return fmt.Errorf("Unable to check nonce '%s': %s", nonce, err)
+		}
+		if seen {
+			return fmt.Errorf("Replayed nonce '%s'", nonce)
 		}
+	}
 
-		h.ServeHTTP(w, r)
-	})
+	return nil
 }
 
-// GenerateHeaderValue takes a content string, calculates an HMAC and returns
-// a properly formated header value including the epoch timestamp
+// GenerateHeaderValue takes a content string, calculates a signature and
+// returns a properly formated header value including the algorithm and the
+// epoch timestamp.
 func (hs *HttpSign) GenerateHeaderValue(value string) string {
 	epoch := time.Now().Unix()
-	signature := calcHMAC(hs.Key, value, epoch)
-	header := formHeader(signature, epoch)
-	return header
+	algorithm := hs.algorithm()
+	nonce, err := generateNonce()
+	if err != nil {
+		return ""
+	}
+	signature, err := algorithm.Sign(formMessage(value, epoch, nonce))
+	if err != nil {
+		return ""
+	}
+	return formHeader(hs.KeyID, algorithm.Name(), string(signature), epoch, nonce)
 }
 
 func (hs *HttpSign) writeInvalid(w http.ResponseWriter) {
@@ -114,33 +226,52 @@ func (hs *HttpSign) writeInvalid(w http.ResponseWriter) {
 	w.Write([]byte(hs.HeaderName + " invalid"))
 }
 
-func calcHMAC(key []byte, value string, epoch int64) string {
+func calcHMAC(key []byte, value string, epoch int64, nonce string) string {
 	mac := hmac.New(sha256.New, key)
-	mac.Write(formMessage(value, epoch))
+	mac.Write(formMessage(value, epoch, nonce))
 	signature := mac.Sum(nil)
 	return string(signature)
 }
 
-func formMessage(value string, epoch int64) []byte {
-	return []byte(fmt.Sprintf("%s%d", value, epoch))
+// formMessage builds the bytes that get signed. nonce is included so that
+// an attacker who intercepts a signed request cannot swap in a fresh,
+// unseen nonce and replay it without invalidating the signature.
+func formMessage(value string, epoch int64, nonce string) []byte {
+	return []byte(fmt.Sprintf("%s%d%s", value, epoch, nonce))
+}
+
+// generateNonce returns a random, URL-safe nonce used for replay
+// protection via NonceStore.
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func formHeader(signature string, epoch int64) string {
+func formHeader(keyID string, alg string, signature string, epoch int64, nonce string) string {
 	b64 := base64.StdEncoding.EncodeToString([]byte(signature))
-	return fmt.Sprintf("%s;%d", b64, epoch)
+	return fmt.Sprintf("%s;%s;%s;%d;%s", keyID, alg, b64, epoch, nonce)
 }
 
-func parseHeader(h string) (signature string, epoch int64, err error) {
-	parts := strings.Split(h, ";")
-	if len(parts) != 2 {
+func parseHeader(h string) (keyID string, alg string, signature string, epoch int64, nonce string, err error) {
+	parts := strings.SplitN(h, ";", 5)
+	if len(parts) != 5 {
 		err = fmt.Errorf("Invalid header format")
 		return
 	}
-	b, err := base64.StdEncoding.DecodeString(parts[0])
+	keyID = parts[0]
+	alg = parts[1]
+	b, err := base64.StdEncoding.DecodeString(parts[2])
 	if err != nil {
 		return
 	}
 	signature = string(b)
-	epoch, err = strconv.ParseInt(parts[1], 10, 64)
+	epoch, err = strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return
+	}
+	nonce = parts[4]
 	return
 }
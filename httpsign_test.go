@@ -16,13 +16,17 @@ func TestParseHeader(t *testing.T) {
 	assert := assert.New(t)
 	val := randomString(25)
 	expectedEpoch := time.Now().Unix()
-	expectedSignature := fmt.Sprintf("%s%d", val, expectedEpoch)
+	expectedNonce := "thenonce"
+	expectedSignature := fmt.Sprintf("%s%d%s", val, expectedEpoch, expectedNonce)
 	encodedSignature := base64.StdEncoding.EncodeToString([]byte(expectedSignature))
-	header := fmt.Sprintf("%s;%d", encodedSignature, expectedEpoch)
-	signature, epoch, err := parseHeader(header)
+	header := fmt.Sprintf(";hmac-sha256;%s;%d;%s", encodedSignature, expectedEpoch, expectedNonce)
+	keyID, alg, signature, epoch, nonce, err := parseHeader(header)
 	assert.NoError(err)
+	assert.Equal("", keyID)
+	assert.Equal("hmac-sha256", alg)
 	assert.Equal(expectedSignature, signature)
 	assert.Equal(expectedEpoch, epoch)
+	assert.Equal(expectedNonce, nonce)
 }
 
 func TestFormHeader(t *testing.T) {
@@ -30,11 +34,12 @@ func TestFormHeader(t *testing.T) {
 	value := randomString(25)
 	key := []byte(randomString(100))
 	epoch := time.Now().Unix()
-	signature := calcHMAC(key, value, epoch)
+	nonce := "thenonce"
+	signature := calcHMAC(key, value, epoch, nonce)
 
 	encodedSignature := base64.StdEncoding.EncodeToString([]byte(signature))
-	expectedHeader := fmt.Sprintf("%s;%d", encodedSignature, epoch)
-	header := formHeader(signature, epoch)
+	expectedHeader := fmt.Sprintf("key1;hmac-sha256;%s;%d;%s", encodedSignature, epoch, nonce)
+	header := formHeader("key1", "hmac-sha256", signature, epoch, nonce)
 	assert.Equal(expectedHeader, header)
 }
 
@@ -43,11 +48,14 @@ func TestGenerateHeaderValue(t *testing.T) {
 	value := randomString(25)
 	key := []byte(randomString(100))
 	hs := New(key)
-	epoch := time.Now().Unix()
-	signature := calcHMAC(key, value, epoch)
-	expectedHeader := formHeader(signature, epoch)
+	hs.KeyID = "key1"
 	header := hs.GenerateHeaderValue(value)
-	assert.Equal(expectedHeader, header)
+
+	keyID, alg, signature, epoch, nonce, err := parseHeader(header)
+	assert.NoError(err)
+	assert.Equal("key1", keyID)
+	assert.Equal("hmac-sha256", alg)
+	assert.Equal(calcHMAC(key, value, epoch, nonce), signature)
 }
 
 func TestSignToProxy(t *testing.T) {
@@ -55,27 +63,22 @@ func TestSignToProxy(t *testing.T) {
 	value := randomString(25)
 	key := []byte(randomString(100))
 	hs := New(key)
-	epoch := time.Now().Unix()
-	signature := calcHMAC(key, value, epoch)
-	expectedHeader := formHeader(signature, epoch)
 
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h := r.Header.Get(hs.HeaderName)
 		fmt.Println(h)
 
-		assert.Equal(expectedHeader, h)
-
 		// force a delay to make sure we can handle latency in the signatures
 		time.Sleep(1100 * time.Millisecond)
 
-		s, e, err := parseHeader(h)
+		keyID, alg, s, e, nonce, err := parseHeader(h)
 		assert.NoError(err)
+		assert.Equal("", keyID)
+		assert.Equal("hmac-sha256", alg)
 
 		// recalculate signature, assume value was passed
-		calcSignature := calcHMAC(key, value, e)
-		assert.Equal(calcSignature, signature)
-		assert.Equal(signature, s)
-		assert.Equal(epoch, e)
+		calcSignature := calcHMAC(key, value, e, nonce)
+		assert.Equal(calcSignature, s)
 		assert.True(time.Now().Unix() < e+int64(6))
 		w.WriteHeader(http.StatusOK)
 	})
@@ -207,7 +210,7 @@ func TestVerifyLogs(t *testing.T) {
 	assert.Contains(lastLogMsg, "Signature mismatch")
 
 	// make request with a stale timestamp
-	header = formHeader("does not matter", 12345) // Long time ago in a galaxy far away...
+	header = formHeader("", "hmac-sha256", "does not matter", 12345, "nonce") // Long time ago in a galaxy far away...
 	req, err = http.NewRequest("GET", ts.URL, nil)
 	assert.Nil(err)
 	req.Header.Set(hs.HeaderName, header)
@@ -0,0 +1,73 @@
+package httpsign
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWithKeyResolver(t *testing.T) {
+	assert := assert.New(t)
+	keys := map[string][]byte{
+		"key1": []byte(randomString(100)),
+		"key2": []byte(randomString(100)),
+	}
+
+	signer := New(keys["key2"])
+	signer.KeyID = "key2"
+
+	verifier := New(nil)
+	verifier.KeyResolver = func(keyID string, r *http.Request) ([]byte, error) {
+		key, ok := keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("unknown keyid '%s'", keyID)
+		}
+		return key, nil
+	}
+
+	value := randomString(25)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.Verify(h, v))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(value))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestVerifyWithKeyResolverUnknownKeyID(t *testing.T) {
+	assert := assert.New(t)
+	signer := New([]byte(randomString(100)))
+	signer.KeyID = "retired-key"
+
+	verifier := New(nil)
+	verifier.KeyResolver = func(keyID string, r *http.Request) ([]byte, error) {
+		return nil, fmt.Errorf("unknown keyid '%s'", keyID)
+	}
+
+	value := randomString(25)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.Verify(h, v))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(value))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,66 @@
+package httpsign
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceStore provides replay protection for HttpSign.Verify. Seen records
+// that nonce was used, valid until expiresAt, and reports whether it had
+// already been recorded — true means the request is a replay and should be
+// rejected.
+//
+// Implementations must be safe for concurrent use. MemoryNonceStore is
+// only suitable for a single instance; for multi-instance deployments back
+// this with a shared store instead (e.g. a Redis SETNX with a TTL, or a
+// memcached add), so replay protection holds across instances.
+type NonceStore interface {
+	Seen(nonce string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryNonceStore is an in-memory NonceStore. Nonces are bucketed by their
+// expiry second so that an expired bucket can be dropped in O(1) rather
+// than scanning every nonce, bounding memory to roughly the number of
+// requests seen within the signer's SecondsAllowance window.
+type MemoryNonceStore struct {
+	mu      sync.Mutex
+	buckets map[int64]map[string]struct{}
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		buckets: make(map[int64]map[string]struct{}),
+	}
+}
+
+// Seen implements NonceStore.
+func (s *MemoryNonceStore) Seen(nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+
+	bucket := expiresAt.Unix()
+	nonces, ok := s.buckets[bucket]
+	if !ok {
+		nonces = make(map[string]struct{})
+		s.buckets[bucket] = nonces
+	}
+	if _, seen := nonces[nonce]; seen {
+		return true, nil
+	}
+	nonces[nonce] = struct{}{}
+	return false, nil
+}
+
+// evictExpired drops buckets whose expiry has already passed. Callers must
+// hold s.mu.
+func (s *MemoryNonceStore) evictExpired() {
+	now := time.Now().Unix()
+	for bucket := range s.buckets {
+		if bucket < now {
+			delete(s.buckets, bucket)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package httpsign
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryNonceStoreRejectsReplay(t *testing.T) {
+	assert := assert.New(t)
+	store := NewMemoryNonceStore()
+	expiresAt := time.Now().Add(time.Minute)
+
+	seen, err := store.Seen("abc", expiresAt)
+	assert.NoError(err)
+	assert.False(seen)
+
+	seen, err = store.Seen("abc", expiresAt)
+	assert.NoError(err)
+	assert.True(seen)
+}
+
+func TestMemoryNonceStoreEvictsExpiredBuckets(t *testing.T) {
+	assert := assert.New(t)
+	store := NewMemoryNonceStore()
+
+	seen, err := store.Seen("abc", time.Now().Add(-time.Minute))
+	assert.NoError(err)
+	assert.False(seen)
+
+	// The bucket for the expired nonce should have been evicted by now, so
+	// the same nonce re-appearing (e.g. a clock jump) is treated as new.
+	seen, err = store.Seen("abc", time.Now().Add(time.Minute))
+	assert.NoError(err)
+	assert.False(seen)
+}
+
+func TestVerifyRejectsReplayedRequest(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	hs := New(key)
+	hs.NonceStore = NewMemoryNonceStore()
+
+	value := randomString(25)
+	v := func(w http.ResponseWriter, r *http.Request) string { return value }
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(hs.Verify(h, v))
+	defer ts.Close()
+
+	header := hs.GenerateHeaderValue(value)
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req.Header.Set(hs.HeaderName, header)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	// Replay the exact same signed header: should be rejected even though
+	// it is still within the timestamp allowance.
+	req2, err := http.NewRequest("GET", ts.URL, nil)
+	assert.Nil(err)
+	req2.Header.Set(hs.HeaderName, header)
+	resp2, err := http.DefaultClient.Do(req2)
+	assert.Nil(err)
+	defer resp2.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp2.StatusCode)
+}
@@ -0,0 +1,104 @@
+package httpsign
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignRequestAndVerifyRequest(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	opts := RequestOptions{Method: true, Path: true, Body: true}
+
+	signer := New(key)
+	verifier := New(key)
+
+	var gotDigest string
+	var gotBody []byte
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotDigest = r.Header.Get("Content-Digest")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(verifier.VerifyRequest(h, opts))
+	defer ts.Close()
+
+	// A proxy server would use SignRequest as middleware in front of the
+	// outgoing call; simulate that here by signing before dispatch.
+	req, err := http.NewRequest("POST", ts.URL+"/widgets", strings.NewReader("payload"))
+	assert.Nil(err)
+	digest, err := computeContentDigest(req)
+	assert.Nil(err)
+	req.Header.Set("Content-Digest", digest)
+	req.Header.Set(signer.HeaderName, signer.GenerateHeaderValue(opts.value(req, true)))
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(digest, gotDigest)
+	assert.Equal("payload", string(gotBody))
+}
+
+func TestVerifyRequestRejectsTamperedMethod(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	opts := RequestOptions{Method: true, Path: true}
+
+	signer := New(key)
+	verifier := New(key)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.VerifyRequest(h, opts))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/widgets", nil)
+	assert.Nil(err)
+
+	// Sign as GET, but send as POST.
+	signHeader := signer.GenerateHeaderValue(opts.value(req, true))
+	req.Method = "POST"
+	req.Header.Set(signer.HeaderName, signHeader)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestVerifyRequestRetriesWithoutQuery(t *testing.T) {
+	assert := assert.New(t)
+	key := []byte(randomString(100))
+	opts := RequestOptions{Path: true, Query: true, RetryWithoutQuery: true}
+
+	signer := New(key)
+	verifier := New(key)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	ts := httptest.NewServer(verifier.VerifyRequest(h, opts))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/widgets?foo=bar", nil)
+	assert.Nil(err)
+
+	// Simulate a peer that signed without the query string even though
+	// this deployment otherwise expects it covered (opts.Query is true),
+	// while the request that actually arrives still carries it.
+	header := signer.GenerateHeaderValue(opts.value(req, false))
+	req.Header.Set(signer.HeaderName, header)
+
+	var lastLogMsg string
+	verifier.LogHook = func(r *http.Request, msg string) { lastLogMsg = msg }
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Contains(lastLogMsg, "without query")
+}